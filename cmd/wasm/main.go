@@ -8,18 +8,33 @@ import (
 	"strings"
 	"syscall/js"
 
-	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter/exporter"
 )
 
-func convertPhoenixToKoinly(this js.Value, args []js.Value) interface{} {
+func convertPhoenix(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return "Error: No CSV data provided"
 	}
 	inputCSV := args[0].String()
 
-	addRoundingCost := false
+	format := "koinly"
 	if len(args) > 1 && args[1].Truthy() {
-		addRoundingCost = args[1].Bool()
+		format = args[1].String()
+	}
+
+	addRoundingCost := true
+	if len(args) > 2 {
+		addRoundingCost = args[2].Bool()
+	}
+
+	splitFees := false
+	if len(args) > 3 {
+		splitFees = args[3].Bool()
+	}
+
+	exp, err := exporter.New(format, addRoundingCost, splitFees)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
 	}
 
 	r := strings.NewReader(inputCSV)
@@ -28,7 +43,7 @@ func convertPhoenixToKoinly(this js.Value, args []js.Value) interface{} {
 	// Enable verbose if needed, though we don't capture logs here easily unless we redirect log output.
 	// converter.Verbose = true
 
-	if err := converter.Convert(r, &buf, addRoundingCost); err != nil {
+	if err := exporter.Convert(r, &buf, exp); err != nil {
 		return fmt.Sprintf("Error converting: %v", err)
 	}
 
@@ -37,7 +52,7 @@ func convertPhoenixToKoinly(this js.Value, args []js.Value) interface{} {
 
 func main() {
 	c := make(chan struct{}, 0)
-	js.Global().Set("convertPhoenixToKoinly", js.FuncOf(convertPhoenixToKoinly))
-	fmt.Println("WASM Initialized: convertPhoenixToKoinly function is ready.")
+	js.Global().Set("convertPhoenix", js.FuncOf(convertPhoenix))
+	fmt.Println("WASM Initialized: convertPhoenix function is ready.")
 	<-c
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -16,30 +15,16 @@ const (
 	KoinlyDateFormat = "2006-01-02 15:04:05 Z"
 	// PhoenixDateFormat defines the date format used in Phoenix CSV exports.
 	PhoenixDateFormat = "2006-01-02T15:04:05.999Z"
-	satsPerBTC        = 100000000
-	msatsPerSat       = 1000
+	// SatsPerBTC is the number of satoshis in one BTC.
+	SatsPerBTC = 100000000
+	// MsatsPerSat is the number of millisats in one satoshi.
+	MsatsPerSat = 1000
 )
 
 var (
 	Verbose bool
 )
 
-// KoinlyRecord represents a single row in the Koinly CSV file.
-type KoinlyRecord struct {
-	Date             string
-	SentAmount       string
-	SentCurrency     string
-	ReceivedAmount   string
-	ReceivedCurrency string
-	FeeAmount        string
-	FeeCurrency      string
-	NetWorthAmount   string
-	NetWorthCurrency string
-	Label            string
-	Description      string
-	TxHash           string
-}
-
 // PhoenixRecord represents a single row in the Phoenix CSV file.
 type PhoenixRecord struct {
 	Timestamp       time.Time
@@ -61,9 +46,17 @@ func ParseIntField(val, name string) int64 {
 	return v
 }
 
-// FormatBTC formats sats to a BTC string.
-func FormatBTC(sats float64) string {
-	return fmt.Sprintf("%.8f", sats/satsPerBTC)
+// FormatBTC formats a whole number of sats directly as a BTC decimal string,
+// without going through floating point, so large sat counts never pick up
+// rounding drift.
+func FormatBTC(sats int64) string {
+	sign := ""
+	abs := sats
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, abs/SatsPerBTC, abs%SatsPerBTC)
 }
 
 // LogVerbose prints messages only if the verbose flag is enabled.
@@ -73,225 +66,201 @@ func LogVerbose(format string, v ...interface{}) {
 	}
 }
 
-// Convert handles the core conversion logic from a reader to a writer.
-func Convert(r io.Reader, w io.Writer, addRoundingCost bool) error {
-	phoenixRecords, err := ReadPhoenixCSV(r)
-	if err != nil {
-		return fmt.Errorf("reading phoenix csv: %w", err)
+// PhoenixSchema describes one version of Phoenix's CSV export format: the
+// column names it uses for each field ParsePhoenixRecord needs. Phoenix has
+// renamed and reordered these columns across versions, so records are
+// parsed by looking up these names in the observed header rather than by
+// fixed position. Use DetectSchema to resolve a PhoenixSchema against an
+// actual header row before parsing records with it.
+type PhoenixSchema struct {
+	Name           string
+	Timestamp      string
+	Type           string
+	AmountMsat     string
+	MiningFeeSat   string
+	ServiceFeeMsat string
+	TxID           string
+	Description    string
+
+	pos map[string]int // column name -> index, set by DetectSchema
+}
+
+// phoenixSchemas lists every Phoenix CSV export format this package knows
+// how to parse. DetectSchema picks the first entry whose required columns
+// are all present in the observed header.
+var phoenixSchemas = []PhoenixSchema{
+	{
+		Name:           "phoenix-v2",
+		Timestamp:      "timestamp",
+		Type:           "type",
+		AmountMsat:     "amount_msat",
+		MiningFeeSat:   "mining_fee_sat",
+		ServiceFeeMsat: "service_fee_msat",
+		TxID:           "payment_hash",
+		Description:    "description",
+	},
+	{
+		Name:           "phoenix-v1",
+		Timestamp:      "date",
+		Type:           "type",
+		AmountMsat:     "amount_msat",
+		MiningFeeSat:   "mining_fee_sat",
+		ServiceFeeMsat: "service_fee_msat",
+		TxID:           "txid",
+		Description:    "description",
+	},
+}
+
+// columns returns the column names s requires, in a stable order, for use
+// both in schema matching and in SchemaMismatchError.
+func (s PhoenixSchema) columns() []string {
+	return []string{s.Timestamp, s.Type, s.AmountMsat, s.MiningFeeSat, s.ServiceFeeMsat, s.TxID, s.Description}
+}
+
+// SchemaMismatchError reports that an observed Phoenix CSV header did not
+// match any known PhoenixSchema, along with the columns each known schema
+// expected, to help diagnose a renamed or reordered export.
+type SchemaMismatchError struct {
+	Observed []string
+	Expected map[string][]string // schema name -> required columns
+}
+
+func (e *SchemaMismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "phoenix CSV header matches no known schema\nobserved columns: %s\n", strings.Join(e.Observed, ", "))
+	for _, s := range phoenixSchemas {
+		fmt.Fprintf(&b, "%s expects: %s\n", s.Name, strings.Join(e.Expected[s.Name], ", "))
+	}
+	return b.String()
+}
+
+// DetectSchema matches header against every known PhoenixSchema and returns
+// a copy of the first match with its column positions resolved against
+// header, ready to pass to ParsePhoenixRecord. If no known schema's
+// required columns are all present in header, it returns a
+// *SchemaMismatchError.
+func DetectSchema(header []string) (*PhoenixSchema, error) {
+	pos := make(map[string]int, len(header))
+	for i, name := range header {
+		pos[name] = i
+	}
+
+	for _, s := range phoenixSchemas {
+		matched := true
+		for _, col := range s.columns() {
+			if _, ok := pos[col]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			resolved := s
+			resolved.pos = pos
+			return &resolved, nil
+		}
 	}
 
-	if err := CreateKoinlyCSV(phoenixRecords, w, addRoundingCost); err != nil {
-		return fmt.Errorf("creating koinly csv: %w", err)
+	expected := make(map[string][]string, len(phoenixSchemas))
+	for _, s := range phoenixSchemas {
+		expected[s.Name] = s.columns()
 	}
-	return nil
+	return nil, &SchemaMismatchError{Observed: header, Expected: expected}
 }
 
-// ReadPhoenixCSV reads a CSV file from the given reader and parses it into a slice of PhoenixRecord.
-func ReadPhoenixCSV(r io.Reader) ([]*PhoenixRecord, error) {
+// column returns record's value for the named column, as resolved by
+// DetectSchema.
+func (s *PhoenixSchema) column(record []string, name string) string {
+	return record[s.pos[name]]
+}
+
+// PhoenixReader incrementally parses records from a Phoenix CSV export, so
+// callers can process arbitrarily large files in O(1) memory instead of
+// materializing every record up front.
+type PhoenixReader struct {
+	r      *csv.Reader
+	schema *PhoenixSchema
+}
+
+// NewPhoenixReader returns a PhoenixReader over r, reading the header row
+// immediately and resolving it against a known PhoenixSchema.
+func NewPhoenixReader(r io.Reader) (*PhoenixReader, error) {
 	reader := csv.NewReader(r)
-	// Read header row to skip it.
-	_, err := reader.Read()
+	header, err := reader.Read()
 	if err != nil {
 		return nil, err
 	}
+	schema, err := DetectSchema(header)
+	if err != nil {
+		return nil, err
+	}
+	return &PhoenixReader{r: reader, schema: schema}, nil
+}
 
-	var records []*PhoenixRecord
+// NextRecord returns the next parsed PhoenixRecord, or io.EOF once the
+// underlying CSV is exhausted. Rows that fail to parse are logged and
+// skipped rather than returned as an error.
+func (pr *PhoenixReader) NextRecord() (*PhoenixRecord, error) {
 	for {
-		record, err := reader.Read()
-		if err == io.EOF { // End of file reached.
-			break
-		}
+		record, err := pr.r.Read()
 		if err != nil {
-			return nil, err
+			return nil, err // includes io.EOF
 		}
 
-		phoenixRecord, err := ParsePhoenixRecord(record)
+		phoenixRecord, err := ParsePhoenixRecord(pr.schema, record)
 		if err != nil {
 			// Log parsing errors but continue processing other records.
 			log.Printf("Error parsing record: %v. Skipping this record.", err)
 			continue
 		}
-		records = append(records, phoenixRecord)
+		return phoenixRecord, nil
 	}
-	return records, nil
 }
 
-// CreateKoinlyCSV takes a slice of PhoenixRecord and writes them to a new CSV file
-// formatted for Koinly.
-func CreateKoinlyCSV(records []*PhoenixRecord, w io.Writer, addCost bool) error {
-	writer := csv.NewWriter(w)
-	defer writer.Flush() // Ensure all buffered writes are committed to the underlying writer.
-
-	// Define the header for the Koinly CSV file.
-	koinlyHeader := []string{
-		"Date",
-		"Sent Amount",
-		"Sent Currency",
-		"Received Amount",
-		"Received Currency",
-		"Fee Amount",
-		"Fee Currency",
-		"Net Worth Amount",
-		"Net Worth Currency",
-		"Label",
-		"Description",
-		"TxHash",
-	}
-	if err := writer.Write(koinlyHeader); err != nil {
-		return err
+// ReadPhoenixCSV reads a CSV file from the given reader and parses it into a
+// slice of PhoenixRecord. Prefer PhoenixReader directly for large inputs,
+// since this materializes every record in memory.
+func ReadPhoenixCSV(r io.Reader) ([]*PhoenixRecord, error) {
+	reader, err := NewPhoenixReader(r)
+	if err != nil {
+		return nil, err
 	}
 
-	var roundingDiff float64
-	// Convert each Phoenix record to a Koinly record and write it to the CSV.
-	for _, p := range records {
-		koinlyRecord, diff := ToKoinlyRecord(p)
-		roundingDiff += diff
-		if err := writer.Write(koinlyRecord.ToStringSlice()); err != nil {
-			return err
+	var records []*PhoenixRecord
+	for {
+		record, err := reader.NextRecord()
+		if err == io.EOF {
+			break
 		}
-	}
-
-	if addCost {
-		roundingSats := int64(math.Round(math.Abs(roundingDiff)))
-		if roundingSats > 0 {
-			costRecord := &KoinlyRecord{
-				Date:        time.Now().UTC().Format(KoinlyDateFormat),
-				FeeAmount:   FormatBTC(float64(roundingSats)),
-				FeeCurrency: "BTC",
-				Label:       "cost",
-				Description: "Adjustment for rounding differences",
-			}
-			if err := writer.Write(costRecord.ToStringSlice()); err != nil {
-				return err
-			}
+		if err != nil {
+			return nil, err
 		}
+		records = append(records, record)
 	}
-	return nil
+	return records, nil
 }
 
-// ParsePhoenixRecord converts a slice of strings (a row from Phoenix CSV) into a PhoenixRecord struct.
-func ParsePhoenixRecord(record []string) (*PhoenixRecord, error) {
-	// Parse timestamp.
-	timestamp, err := time.Parse(PhoenixDateFormat, record[0])
+// ParsePhoenixRecord converts a slice of strings (a row from Phoenix CSV)
+// into a PhoenixRecord struct, looking up each field by column name via
+// schema rather than by fixed position. schema must have been resolved
+// against this CSV's header by DetectSchema.
+func ParsePhoenixRecord(schema *PhoenixSchema, record []string) (*PhoenixRecord, error) {
+	rawTimestamp := schema.column(record, schema.Timestamp)
+	timestamp, err := time.Parse(PhoenixDateFormat, rawTimestamp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse timestamp '%s': %w", record[0], err)
+		return nil, fmt.Errorf("failed to parse timestamp '%s': %w", rawTimestamp, err)
 	}
 
-	amountMillisats := ParseIntField(record[3], "amount_msat")
-	miningFeeSat := ParseIntField(record[6], "mining_fee_sat")
-	serviceFeeMsat := ParseIntField(record[8], "service_fee_msat")
+	amountMillisats := ParseIntField(schema.column(record, schema.AmountMsat), schema.AmountMsat)
+	miningFeeSat := ParseIntField(schema.column(record, schema.MiningFeeSat), schema.MiningFeeSat)
+	serviceFeeMsat := ParseIntField(schema.column(record, schema.ServiceFeeMsat), schema.ServiceFeeMsat)
 
 	return &PhoenixRecord{
 		Timestamp:       timestamp,
-		Type:            record[2],
+		Type:            schema.column(record, schema.Type),
 		AmountMillisats: amountMillisats,
 		MiningFeeSat:    miningFeeSat,
 		ServiceFeeMsat:  serviceFeeMsat,
-		TransactionID:   record[11],
-		Description:     record[13],
+		TransactionID:   schema.column(record, schema.TxID),
+		Description:     schema.column(record, schema.Description),
 	}, nil
 }
-
-// ToKoinlyRecord converts a PhoenixRecord into a KoinlyRecord.
-// It maps different Phoenix transaction types to appropriate Koinly fields (Sent, Received, Fee).
-func ToKoinlyRecord(p *PhoenixRecord) (*KoinlyRecord, float64) {
-	// Note: Fees are often included in the sent/received amounts in Phoenix,
-	// so they are not always tracked separately in Koinly unless explicitly a fee-only transaction.
-	k := &KoinlyRecord{
-		Date:        p.Timestamp.Format(KoinlyDateFormat),
-		TxHash:      p.TransactionID,
-		Description: p.Description,
-	}
-
-	// Convert amount from millisats to sats.
-	sats := float64(p.AmountMillisats) / msatsPerSat
-	absSats := math.Abs(sats)
-	LogVerbose("Processing Phoenix Record: %+v", p)
-	LogVerbose("Calculated Sats: %.8f", sats)
-
-	var diff float64
-	// Determine the Koinly record type based on Phoenix transaction type.
-	switch p.Type {
-	case "lightning_received":
-		amt := FormatBTC(sats)
-		k.ReceivedAmount = amt
-		k.ReceivedCurrency = "BTC"
-		k.Label = "lightning"
-		LogVerbose("Type: lightning_received -> ReceivedAmount=%s BTC", k.ReceivedAmount)
-		v, _ := strconv.ParseFloat(amt, 64)
-		diff = sats - v*satsPerBTC
-	case "lightning_sent":
-		// For sent transactions, amount_msat is negative. Use absolute value.
-		amt := FormatBTC(absSats)
-		k.SentAmount = amt
-		k.SentCurrency = "BTC"
-		k.Label = "lightning"
-		LogVerbose("Type: lightning_sent -> SentAmount=%s BTC", k.SentAmount)
-		v, _ := strconv.ParseFloat(amt, 64)
-		diff = sats - (-v * satsPerBTC)
-	case "swap_in", "legacy_swap_in":
-		// Swap-in is a receipt of funds.
-		amt := FormatBTC(sats)
-		k.ReceivedAmount = amt
-		k.ReceivedCurrency = "BTC"
-		k.Label = "transfer"
-		LogVerbose("Type: %s -> ReceivedAmount=%s BTC", p.Type, k.ReceivedAmount)
-		v, _ := strconv.ParseFloat(amt, 64)
-		diff = sats - v*satsPerBTC
-	case "swap_out":
-		// Swap-out is a sending of funds.
-		amt := FormatBTC(absSats)
-		k.SentAmount = amt
-		k.SentCurrency = "BTC"
-		k.Label = "transfer"
-		LogVerbose("Type: swap_out -> SentAmount=%s BTC", k.SentAmount)
-		v, _ := strconv.ParseFloat(amt, 64)
-		diff = sats - (-v * satsPerBTC)
-	case "channel_open", "legacy_pay_to_open":
-		// Channel open is treated as a deposit.
-		amt := FormatBTC(sats)
-		k.ReceivedAmount = amt
-		k.ReceivedCurrency = "BTC"
-		k.Label = "deposit"
-		LogVerbose("Type: %s -> ReceivedAmount=%s BTC", p.Type, k.ReceivedAmount)
-		v, _ := strconv.ParseFloat(amt, 64)
-		diff = sats - v*satsPerBTC
-	case "channel_close":
-		// Channel close is treated as a cost (fee) in Koinly, as it's often just a fee settlement.
-		k.SentAmount = ""
-		k.SentCurrency = ""
-		k.ReceivedAmount = ""
-		k.ReceivedCurrency = ""
-		amt := FormatBTC(absSats)
-		k.FeeAmount = amt
-		k.FeeCurrency = "BTC"
-		k.Label = "cost"
-		LogVerbose("Type: channel_close -> FeeAmount=%s BTC", k.FeeAmount)
-		v, _ := strconv.ParseFloat(amt, 64)
-		diff = sats - (-v * satsPerBTC)
-	default:
-		// Log unknown transaction types for awareness.
-		log.Printf("Unknown transaction type for Koinly conversion: %s. This transaction will not be fully converted.", p.Type)
-	}
-
-	return k, diff
-}
-
-// ToStringSlice converts a KoinlyRecord struct into a slice of strings,
-// suitable for writing as a row in a CSV file.
-func (k *KoinlyRecord) ToStringSlice() []string {
-	return []string{
-		k.Date,
-		k.SentAmount,
-		k.SentCurrency,
-		k.ReceivedAmount,
-		k.ReceivedCurrency,
-		k.FeeAmount,
-		k.FeeCurrency,
-		k.NetWorthAmount,
-		k.NetWorthCurrency,
-		k.Label,
-		k.Description,
-		k.TxHash,
-	}
-}
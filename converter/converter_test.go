@@ -1,32 +1,44 @@
 package converter
 
 import (
-	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"testing"
 	"time"
 )
 
-func TestParsePhoenixRecord(t *testing.T) {
+func TestParsePhoenixRecordV1Schema(t *testing.T) {
+	header := []string{
+		"date", "id", "type", "amount_msat", "fee_credit_msat", "fee_debit_msat",
+		"mining_fee_sat", "fee_type", "service_fee_msat", "original_fee_msat",
+		"payment_hash", "txid", "destination", "description",
+	}
 	record := []string{
-		"2024-05-01T12:00:00.000Z", // timestamp
-		"unused1",
-		"lightning_received", // type
-		"123456789",          // amount_msat
+		"2024-05-01T12:00:00.000Z", // date
+		"1",                        // id
+		"lightning_received",       // type
+		"123456789",                // amount_msat
 		"unused2",
 		"unused3",
-		"0", // mining fee sat
+		"0", // mining_fee_sat
 		"unused4",
-		"0", // service fee msat
+		"0", // service_fee_msat
 		"unused5",
 		"unused6",
-		"txid123", // transaction id
+		"txid123", // txid
 		"unused7",
 		"test description", // description
 	}
-	p, err := ParsePhoenixRecord(record)
+
+	schema, err := DetectSchema(header)
+	if err != nil {
+		t.Fatalf("unexpected schema detection error: %v", err)
+	}
+	if schema.Name != "phoenix-v1" {
+		t.Fatalf("expected phoenix-v1, detected %s", schema.Name)
+	}
+
+	p, err := ParsePhoenixRecord(schema, record)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -38,62 +50,57 @@ func TestParsePhoenixRecord(t *testing.T) {
 	}
 }
 
-func TestToKoinlyRecordLightningReceived(t *testing.T) {
-	p := &PhoenixRecord{
-		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
-		Type:            "lightning_received",
-		AmountMillisats: 1000000000, // 1,000,000 sats
-		TransactionID:   "tx1",
-		Description:     "desc",
+func TestParsePhoenixRecordV2Schema(t *testing.T) {
+	header := []string{"timestamp", "type", "amount_msat", "mining_fee_sat", "service_fee_msat", "payment_hash", "description"}
+	record := []string{
+		"2024-06-15T08:30:00.000Z", // timestamp
+		"channel_open",             // type
+		"500000000",                // amount_msat
+		"300",                      // mining_fee_sat
+		"0",                        // service_fee_msat
+		"hash456",                  // payment_hash
+		"newer export",             // description
 	}
-	k, diff := ToKoinlyRecord(p)
-	if math.Abs(diff) > 1e-9 {
-		t.Errorf("expected zero rounding diff, got %f", diff)
+
+	schema, err := DetectSchema(header)
+	if err != nil {
+		t.Fatalf("unexpected schema detection error: %v", err)
 	}
-	if k.ReceivedAmount != "0.01000000" || k.ReceivedCurrency != "BTC" || k.Label != "lightning" {
-		t.Errorf("unexpected koinly record: %+v", k)
+	if schema.Name != "phoenix-v2" {
+		t.Fatalf("expected phoenix-v2, detected %s", schema.Name)
 	}
-}
 
-func TestToKoinlyRecordLightningSent(t *testing.T) {
-	p := &PhoenixRecord{
-		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
-		Type:            "lightning_sent",
-		AmountMillisats: -200000000, // -200,000 sats
-		TransactionID:   "tx2",
-		Description:     "desc",
-	}
-	k, diff := ToKoinlyRecord(p)
-	if math.Abs(diff) > 1e-9 {
-		t.Errorf("expected zero rounding diff, got %f", diff)
+	p, err := ParsePhoenixRecord(schema, record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if k.SentAmount != "0.00200000" || k.SentCurrency != "BTC" || k.Label != "lightning" {
-		t.Errorf("unexpected koinly record: %+v", k)
+	if p.Type != "channel_open" || p.AmountMillisats != 500000000 || p.MiningFeeSat != 300 || p.ServiceFeeMsat != 0 || p.TransactionID != "hash456" || p.Description != "newer export" {
+		t.Errorf("parsed struct mismatch: %+v", p)
 	}
 }
 
-func TestToKoinlyRecordChannelClose(t *testing.T) {
-	p := &PhoenixRecord{
-		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
-		Type:            "channel_close",
-		AmountMillisats: -150000, // -150 sats
-		TransactionID:   "tx3",
-		Description:     "desc",
+func TestDetectSchemaMismatch(t *testing.T) {
+	header := []string{"date", "type", "amount"}
+	_, err := DetectSchema(header)
+	if err == nil {
+		t.Fatal("expected an error for an unrecognized header")
+	}
+	mismatch, ok := err.(*SchemaMismatchError)
+	if !ok {
+		t.Fatalf("expected *SchemaMismatchError, got %T", err)
 	}
-	k, diff := ToKoinlyRecord(p)
-	if math.Abs(diff) > 1e-9 {
-		t.Errorf("expected zero rounding diff, got %f", diff)
+	if len(mismatch.Observed) != len(header) {
+		t.Errorf("expected observed header to be recorded, got %v", mismatch.Observed)
 	}
-	if k.FeeAmount != "0.00000150" || k.FeeCurrency != "BTC" || k.Label != "cost" {
-		t.Errorf("unexpected koinly record: %+v", k)
+	if len(mismatch.Expected) != len(phoenixSchemas) {
+		t.Errorf("expected one entry per known schema, got %d", len(mismatch.Expected))
 	}
 }
 
-func TestFinalBalanceSampleCSV(t *testing.T) {
-	// Need to fix path to testdata since we are in converter package
-	f, err := os.Open(filepath.Join("..", "testdata", "sample_phoenix.csv"))
+func TestReadPhoenixCSVV2SchemaFixture(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "testdata", "sample_phoenix_v2.csv"))
 	if err != nil {
-		t.Fatalf("failed to read csv: %v", err)
+		t.Fatalf("failed to open fixture: %v", err)
 	}
 	defer f.Close()
 
@@ -101,36 +108,13 @@ func TestFinalBalanceSampleCSV(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to read csv records: %v", err)
 	}
-	var total float64
-	for _, p := range records {
-		k, diff := ToKoinlyRecord(p)
-		if math.Abs(diff) > 1e-9 {
-			t.Errorf("unexpected rounding diff %f", diff)
-		}
-		if k.ReceivedAmount != "" {
-			v, err := strconv.ParseFloat(k.ReceivedAmount, 64)
-			if err != nil {
-				t.Fatalf("bad received amount: %v", err)
-			}
-			total += v
-		}
-		if k.SentAmount != "" {
-			v, err := strconv.ParseFloat(k.SentAmount, 64)
-			if err != nil {
-				t.Fatalf("bad sent amount: %v", err)
-			}
-			total -= v
-		}
-		if k.FeeAmount != "" {
-			v, err := strconv.ParseFloat(k.FeeAmount, 64)
-			if err != nil {
-				t.Fatalf("bad fee amount: %v", err)
-			}
-			total -= v
-		}
-	}
-	expected := 0.00157
-	if math.Abs(total-expected) > 1e-8 {
-		t.Errorf("expected final balance %.8f BTC, got %.8f BTC", expected, total)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Type != "lightning_received" || records[0].AmountMillisats != 100000000 || records[0].TransactionID != "hashA" {
+		t.Errorf("unexpected first record: %+v", records[0])
+	}
+	if records[1].Type != "channel_open" || records[1].MiningFeeSat != 150 || records[1].TransactionID != "hashB" {
+		t.Errorf("unexpected second record: %+v", records[1])
 	}
 }
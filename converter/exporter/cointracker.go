@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+)
+
+// coinTrackerDateFormat is the UTC ISO timestamp format CoinTracker expects.
+const coinTrackerDateFormat = time.RFC3339
+
+// coinTrackerRecord represents a single row in CoinTracker's CSV schema.
+type coinTrackerRecord struct {
+	Date             string
+	ReceivedQuantity string
+	ReceivedCurrency string
+	SentQuantity     string
+	SentCurrency     string
+	FeeAmount        string
+	FeeCurrency      string
+	Tag              string
+}
+
+func (c *coinTrackerRecord) toRow() Row {
+	return Row{
+		c.Date,
+		c.ReceivedQuantity,
+		c.ReceivedCurrency,
+		c.SentQuantity,
+		c.SentCurrency,
+		c.FeeAmount,
+		c.FeeCurrency,
+		c.Tag,
+	}
+}
+
+// CoinTrackerExporter converts PhoenixRecords into CoinTracker's CSV schema.
+type CoinTrackerExporter struct {
+	addRoundingCost bool
+	// roundingDiffMsats is the running sum, in millisats, of every
+	// msat->sat truncation performed by Convert.
+	roundingDiffMsats int64
+}
+
+// NewCoinTrackerExporter returns a CoinTrackerExporter. When addRoundingCost
+// is true, a trailing fee-only row is emitted by Finalize to account for any
+// sats lost to msat->sat truncation across all converted records.
+func NewCoinTrackerExporter(addRoundingCost bool) *CoinTrackerExporter {
+	return &CoinTrackerExporter{addRoundingCost: addRoundingCost}
+}
+
+func (e *CoinTrackerExporter) Header() []string {
+	return []string{
+		"Date",
+		"Received Quantity",
+		"Received Currency",
+		"Sent Quantity",
+		"Sent Currency",
+		"Fee Amount",
+		"Fee Currency",
+		"Tag",
+	}
+}
+
+// Convert maps a PhoenixRecord into a CoinTracker row.
+func (e *CoinTrackerExporter) Convert(p *converter.PhoenixRecord) ([]Row, error) {
+	c := &coinTrackerRecord{
+		Date: p.Timestamp.UTC().Format(coinTrackerDateFormat),
+	}
+
+	sats, leftover := splitSats(p.AmountMillisats)
+	absSats := sats
+	if absSats < 0 {
+		absSats = -absSats
+	}
+
+	switch p.Type {
+	case "lightning_received":
+		c.ReceivedQuantity = converter.FormatBTC(sats)
+		c.ReceivedCurrency = "BTC"
+		c.Tag = "lightning"
+		e.roundingDiffMsats += leftover
+	case "lightning_sent":
+		c.SentQuantity = converter.FormatBTC(absSats)
+		c.SentCurrency = "BTC"
+		c.Tag = "lightning"
+		e.roundingDiffMsats += leftover
+	case "swap_in", "legacy_swap_in":
+		c.ReceivedQuantity = converter.FormatBTC(sats)
+		c.ReceivedCurrency = "BTC"
+		c.Tag = "transfer"
+		e.roundingDiffMsats += leftover
+	case "swap_out":
+		c.SentQuantity = converter.FormatBTC(absSats)
+		c.SentCurrency = "BTC"
+		c.Tag = "transfer"
+		e.roundingDiffMsats += leftover
+	case "channel_open", "legacy_pay_to_open":
+		c.ReceivedQuantity = converter.FormatBTC(sats)
+		c.ReceivedCurrency = "BTC"
+		c.Tag = "deposit"
+		e.roundingDiffMsats += leftover
+	case "channel_close":
+		c.FeeAmount = converter.FormatBTC(absSats)
+		c.FeeCurrency = "BTC"
+		c.Tag = "cost"
+		e.roundingDiffMsats += leftover
+	default:
+		converter.LogVerbose("Unknown transaction type for CoinTracker conversion: %s. This transaction will not be fully converted.", p.Type)
+	}
+
+	return []Row{c.toRow()}, nil
+}
+
+// Finalize emits a trailing fee-only row accounting for sats lost to
+// msat->sat truncation across all converted records, if addRoundingCost was
+// requested and the accumulated leftover is at least half a sat (500
+// millisats).
+func (e *CoinTrackerExporter) Finalize() []Row {
+	if !e.addRoundingCost {
+		return nil
+	}
+
+	leftover := e.roundingDiffMsats
+	if leftover < 0 {
+		leftover = -leftover
+	}
+	if leftover < 500 {
+		return nil
+	}
+	roundingSats := (leftover + 500) / converter.MsatsPerSat
+	if roundingSats <= 0 {
+		return nil
+	}
+
+	costRecord := &coinTrackerRecord{
+		Date:        time.Now().UTC().Format(coinTrackerDateFormat),
+		FeeAmount:   converter.FormatBTC(roundingSats),
+		FeeCurrency: "BTC",
+		Tag:         "cost",
+	}
+	return []Row{costRecord.toRow()}
+}
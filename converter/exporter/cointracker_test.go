@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+)
+
+func TestCoinTrackerExporterLightningReceived(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "lightning_received",
+		AmountMillisats: 1000000000, // 1,000,000 sats
+		TransactionID:   "tx1",
+	}
+	e := NewCoinTrackerExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	c := rows[0]
+	if c[1] != "0.01000000" || c[2] != "BTC" || c[7] != "lightning" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+	if e.roundingDiffMsats != 0 {
+		t.Errorf("expected zero rounding diff, got %d", e.roundingDiffMsats)
+	}
+}
+
+func TestCoinTrackerExporterLightningSent(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "lightning_sent",
+		AmountMillisats: -200000000, // -200,000 sats
+		TransactionID:   "tx2",
+	}
+	e := NewCoinTrackerExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[3] != "0.00200000" || c[4] != "BTC" || c[7] != "lightning" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCoinTrackerExporterSwapIn(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "swap_in",
+		AmountMillisats: 500000000, // 500,000 sats
+		TransactionID:   "tx3",
+	}
+	e := NewCoinTrackerExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[1] != "0.00500000" || c[2] != "BTC" || c[7] != "transfer" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCoinTrackerExporterSwapOut(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "swap_out",
+		AmountMillisats: -300000000, // -300,000 sats
+		TransactionID:   "tx4",
+	}
+	e := NewCoinTrackerExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[3] != "0.00300000" || c[4] != "BTC" || c[7] != "transfer" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCoinTrackerExporterChannelOpen(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "channel_open",
+		AmountMillisats: 250000000, // 250,000 sats
+		TransactionID:   "tx5",
+	}
+	e := NewCoinTrackerExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[1] != "0.00250000" || c[2] != "BTC" || c[7] != "deposit" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCoinTrackerExporterChannelClose(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "channel_close",
+		AmountMillisats: -150000, // -150 sats
+		TransactionID:   "tx6",
+	}
+	e := NewCoinTrackerExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[5] != "0.00000150" || c[6] != "BTC" || c[7] != "cost" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+	if e.roundingDiffMsats != 0 {
+		t.Errorf("expected zero rounding diff, got %d", e.roundingDiffMsats)
+	}
+}
+
+func TestCoinTrackerExporterFinalizeRoundingCost(t *testing.T) {
+	e := NewCoinTrackerExporter(true)
+	// Three lightning_received records, each truncating a fractional sat,
+	// accumulating well past the 500-millisat adjustment threshold.
+	for i := 0; i < 3; i++ {
+		p := &converter.PhoenixRecord{
+			Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Type:            "lightning_received",
+			AmountMillisats: 1999, // 1 sat + 999 leftover millisats
+			TransactionID:   "tx",
+		}
+		if _, err := e.Convert(p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if e.roundingDiffMsats != 2997 {
+		t.Fatalf("expected accumulated leftover of 2997 msats, got %d", e.roundingDiffMsats)
+	}
+
+	rows := e.Finalize()
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 adjustment row, got %d", len(rows))
+	}
+	c := rows[0]
+	if c[5] != "0.00000003" || c[6] != "BTC" || c[7] != "cost" {
+		t.Errorf("unexpected adjustment row: %+v", c)
+	}
+}
+
+func TestCoinTrackerExporterFinalizeBelowThreshold(t *testing.T) {
+	e := NewCoinTrackerExporter(true)
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "lightning_received",
+		AmountMillisats: 100499, // leftover of 499 msats, below the threshold
+		TransactionID:   "tx",
+	}
+	if _, err := e.Convert(p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows := e.Finalize(); rows != nil {
+		t.Errorf("expected no adjustment row below threshold, got %+v", rows)
+	}
+}
@@ -0,0 +1,157 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+)
+
+// cointrackingDateFormat is the date format CoinTracking's custom exchange
+// import expects.
+const cointrackingDateFormat = "2006-01-02 15:04:05"
+
+// cointrackingRecord represents a single row in CoinTracking's custom
+// exchange import CSV schema.
+type cointrackingRecord struct {
+	Type         string
+	BuyAmount    string
+	BuyCurrency  string
+	SellAmount   string
+	SellCurrency string
+	Fee          string
+	FeeCurrency  string
+	Exchange     string
+	Comment      string
+	Date         string
+}
+
+func (c *cointrackingRecord) toRow() Row {
+	return Row{
+		c.Type,
+		c.BuyAmount,
+		c.BuyCurrency,
+		c.SellAmount,
+		c.SellCurrency,
+		c.Fee,
+		c.FeeCurrency,
+		c.Exchange,
+		c.Comment,
+		c.Date,
+	}
+}
+
+// CointrackingExporter converts PhoenixRecords into CoinTracking's custom
+// exchange import CSV schema.
+type CointrackingExporter struct {
+	addRoundingCost bool
+	// roundingDiffMsats is the running sum, in millisats, of every
+	// msat->sat truncation performed by Convert.
+	roundingDiffMsats int64
+}
+
+// NewCointrackingExporter returns a CointrackingExporter. When
+// addRoundingCost is true, a trailing "Other Fee" row is emitted by Finalize
+// to account for any sats lost to msat->sat truncation across all converted
+// records.
+func NewCointrackingExporter(addRoundingCost bool) *CointrackingExporter {
+	return &CointrackingExporter{addRoundingCost: addRoundingCost}
+}
+
+func (e *CointrackingExporter) Header() []string {
+	return []string{
+		"Type",
+		"Buy Amount",
+		"Buy Currency",
+		"Sell Amount",
+		"Sell Currency",
+		"Fee",
+		"Fee Currency",
+		"Exchange",
+		"Comment",
+		"Date",
+	}
+}
+
+// Convert maps a PhoenixRecord into a CoinTracking row.
+func (e *CointrackingExporter) Convert(p *converter.PhoenixRecord) ([]Row, error) {
+	c := &cointrackingRecord{
+		Exchange: "Phoenix",
+		Comment:  p.Description,
+		Date:     p.Timestamp.Format(cointrackingDateFormat),
+	}
+
+	sats, leftover := splitSats(p.AmountMillisats)
+	absSats := sats
+	if absSats < 0 {
+		absSats = -absSats
+	}
+
+	switch p.Type {
+	case "lightning_received":
+		c.Type = "Deposit"
+		c.BuyAmount = converter.FormatBTC(sats)
+		c.BuyCurrency = "BTC"
+		e.roundingDiffMsats += leftover
+	case "lightning_sent":
+		c.Type = "Withdrawal"
+		c.SellAmount = converter.FormatBTC(absSats)
+		c.SellCurrency = "BTC"
+		e.roundingDiffMsats += leftover
+	case "swap_in", "legacy_swap_in":
+		c.Type = "Deposit"
+		c.BuyAmount = converter.FormatBTC(sats)
+		c.BuyCurrency = "BTC"
+		e.roundingDiffMsats += leftover
+	case "swap_out":
+		c.Type = "Withdrawal"
+		c.SellAmount = converter.FormatBTC(absSats)
+		c.SellCurrency = "BTC"
+		e.roundingDiffMsats += leftover
+	case "channel_open", "legacy_pay_to_open":
+		c.Type = "Deposit"
+		c.BuyAmount = converter.FormatBTC(sats)
+		c.BuyCurrency = "BTC"
+		e.roundingDiffMsats += leftover
+	case "channel_close":
+		c.Type = "Other Fee"
+		c.Fee = converter.FormatBTC(absSats)
+		c.FeeCurrency = "BTC"
+		e.roundingDiffMsats += leftover
+	default:
+		converter.LogVerbose("Unknown transaction type for Cointracking conversion: %s. This transaction will not be fully converted.", p.Type)
+	}
+
+	return []Row{c.toRow()}, nil
+}
+
+// Finalize emits a trailing "Other Fee" row accounting for sats lost to
+// msat->sat truncation across all converted records, if addRoundingCost was
+// requested and the accumulated leftover is at least half a sat (500
+// millisats).
+func (e *CointrackingExporter) Finalize() []Row {
+	if !e.addRoundingCost {
+		return nil
+	}
+
+	leftover := e.roundingDiffMsats
+	if leftover < 0 {
+		leftover = -leftover
+	}
+	if leftover < 500 {
+		return nil
+	}
+	roundingSats := (leftover + 500) / converter.MsatsPerSat
+	if roundingSats <= 0 {
+		return nil
+	}
+
+	costRecord := &cointrackingRecord{
+		Type:        "Other Fee",
+		Fee:         converter.FormatBTC(roundingSats),
+		FeeCurrency: "BTC",
+		Exchange:    "Phoenix",
+		Comment:     "Adjustment for rounding differences",
+		Date:        time.Now().UTC().Format(cointrackingDateFormat),
+	}
+	return []Row{costRecord.toRow()}
+}
@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+)
+
+func TestCointrackingExporterLightningReceived(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "lightning_received",
+		AmountMillisats: 1000000000, // 1,000,000 sats
+		TransactionID:   "tx1",
+	}
+	e := NewCointrackingExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	c := rows[0]
+	if c[0] != "Deposit" || c[1] != "0.01000000" || c[2] != "BTC" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+	if e.roundingDiffMsats != 0 {
+		t.Errorf("expected zero rounding diff, got %d", e.roundingDiffMsats)
+	}
+}
+
+func TestCointrackingExporterLightningSent(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "lightning_sent",
+		AmountMillisats: -200000000, // -200,000 sats
+		TransactionID:   "tx2",
+	}
+	e := NewCointrackingExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[0] != "Withdrawal" || c[3] != "0.00200000" || c[4] != "BTC" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCointrackingExporterSwapIn(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "swap_in",
+		AmountMillisats: 500000000, // 500,000 sats
+		TransactionID:   "tx3",
+	}
+	e := NewCointrackingExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[0] != "Deposit" || c[1] != "0.00500000" || c[2] != "BTC" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCointrackingExporterSwapOut(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "swap_out",
+		AmountMillisats: -300000000, // -300,000 sats
+		TransactionID:   "tx4",
+	}
+	e := NewCointrackingExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[0] != "Withdrawal" || c[3] != "0.00300000" || c[4] != "BTC" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCointrackingExporterChannelOpen(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "channel_open",
+		AmountMillisats: 250000000, // 250,000 sats
+		TransactionID:   "tx5",
+	}
+	e := NewCointrackingExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[0] != "Deposit" || c[1] != "0.00250000" || c[2] != "BTC" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+}
+
+func TestCointrackingExporterChannelClose(t *testing.T) {
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "channel_close",
+		AmountMillisats: -150000, // -150 sats
+		TransactionID:   "tx6",
+	}
+	e := NewCointrackingExporter(false)
+	rows, err := e.Convert(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := rows[0]
+	if c[0] != "Other Fee" || c[5] != "0.00000150" || c[6] != "BTC" {
+		t.Errorf("unexpected row: %+v", c)
+	}
+	if e.roundingDiffMsats != 0 {
+		t.Errorf("expected zero rounding diff, got %d", e.roundingDiffMsats)
+	}
+}
+
+func TestCointrackingExporterFinalizeRoundingCost(t *testing.T) {
+	e := NewCointrackingExporter(true)
+	// Three lightning_received records, each truncating a fractional sat,
+	// accumulating well past the 500-millisat adjustment threshold.
+	for i := 0; i < 3; i++ {
+		p := &converter.PhoenixRecord{
+			Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Type:            "lightning_received",
+			AmountMillisats: 1999, // 1 sat + 999 leftover millisats
+			TransactionID:   "tx",
+		}
+		if _, err := e.Convert(p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if e.roundingDiffMsats != 2997 {
+		t.Fatalf("expected accumulated leftover of 2997 msats, got %d", e.roundingDiffMsats)
+	}
+
+	rows := e.Finalize()
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 adjustment row, got %d", len(rows))
+	}
+	c := rows[0]
+	if c[0] != "Other Fee" || c[5] != "0.00000003" || c[6] != "BTC" {
+		t.Errorf("unexpected adjustment row: %+v", c)
+	}
+}
+
+func TestCointrackingExporterFinalizeBelowThreshold(t *testing.T) {
+	e := NewCointrackingExporter(true)
+	p := &converter.PhoenixRecord{
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Type:            "lightning_received",
+		AmountMillisats: 100499, // leftover of 499 msats, below the threshold
+		TransactionID:   "tx",
+	}
+	if _, err := e.Convert(p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows := e.Finalize(); rows != nil {
+		t.Errorf("expected no adjustment row below threshold, got %+v", rows)
+	}
+}
@@ -0,0 +1,108 @@
+// Package exporter defines the pluggable interface used to turn Phoenix
+// ledger records into the CSV schema expected by a specific tax platform,
+// along with the concrete Koinly, CoinTracker, and Cointracking exporters.
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+)
+
+// Row is a single output CSV row, independent of any particular platform's column layout.
+type Row []string
+
+// Exporter converts PhoenixRecords into the CSV rows for a specific tax platform.
+type Exporter interface {
+	// Header returns the column headers for this exporter's CSV schema.
+	Header() []string
+	// Convert maps a single PhoenixRecord into zero or more output rows.
+	Convert(p *converter.PhoenixRecord) ([]Row, error)
+	// Finalize returns any trailing rows (e.g. a rounding-adjustment entry)
+	// once all records have been processed.
+	Finalize() []Row
+}
+
+// New constructs the Exporter for the named tax platform format. Supported
+// formats are "koinly", "cointracker", and "cointracking". When
+// addRoundingCost is true, exporters that support it will emit a trailing
+// adjustment row to account for sats lost to msat->sat truncation. splitFees
+// is only honored by the Koinly exporter; it is ignored elsewhere.
+func New(format string, addRoundingCost, splitFees bool) (Exporter, error) {
+	switch format {
+	case "koinly":
+		return NewKoinlyExporter(addRoundingCost, splitFees), nil
+	case "cointracker":
+		return NewCoinTrackerExporter(addRoundingCost), nil
+	case "cointracking":
+		return NewCointrackingExporter(addRoundingCost), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// Convert streams Phoenix CSV records from r straight to w in exp's schema,
+// one record at a time, so memory use stays O(1) regardless of input size.
+// Only exp's own per-record state (e.g. a running rounding-diff accumulator)
+// carries across records; the final adjustment row is emitted by Finalize
+// once r is exhausted.
+func Convert(r io.Reader, w io.Writer, exp Exporter) error {
+	reader, err := converter.NewPhoenixReader(r)
+	if err != nil {
+		return fmt.Errorf("reading phoenix csv: %w", err)
+	}
+
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	if err := writeHeader(writer, exp); err != nil {
+		return fmt.Errorf("writing export csv: %w", err)
+	}
+
+	for {
+		p, err := reader.NextRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading phoenix csv: %w", err)
+		}
+
+		if err := writeRecord(writer, exp, p); err != nil {
+			return fmt.Errorf("writing export csv: %w", err)
+		}
+	}
+
+	if err := writeFinal(writer, exp); err != nil {
+		return fmt.Errorf("writing export csv: %w", err)
+	}
+	return nil
+}
+
+func writeHeader(w *csv.Writer, exp Exporter) error {
+	return w.Write(exp.Header())
+}
+
+func writeRecord(w *csv.Writer, exp Exporter, p *converter.PhoenixRecord) error {
+	rows, err := exp.Convert(p)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFinal(w *csv.Writer, exp Exporter) error {
+	for _, row := range exp.Finalize() {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return w.Error()
+}
@@ -0,0 +1,281 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter/pricing"
+)
+
+// koinlyRecord represents a single row in Koinly's universal CSV schema.
+type koinlyRecord struct {
+	Date             string
+	SentAmount       string
+	SentCurrency     string
+	ReceivedAmount   string
+	ReceivedCurrency string
+	FeeAmount        string
+	FeeCurrency      string
+	NetWorthAmount   string
+	NetWorthCurrency string
+	Label            string
+	Description      string
+	TxHash           string
+}
+
+func (k *koinlyRecord) toRow() Row {
+	return Row{
+		k.Date,
+		k.SentAmount,
+		k.SentCurrency,
+		k.ReceivedAmount,
+		k.ReceivedCurrency,
+		k.FeeAmount,
+		k.FeeCurrency,
+		k.NetWorthAmount,
+		k.NetWorthCurrency,
+		k.Label,
+		k.Description,
+		k.TxHash,
+	}
+}
+
+// KoinlyExporter converts PhoenixRecords into Koinly's universal CSV schema.
+type KoinlyExporter struct {
+	addRoundingCost bool
+	splitFees       bool
+	// roundingDiffMsats is the running sum, in millisats, of every
+	// msat->sat truncation performed by Convert. It is exact integer
+	// arithmetic: msat = sats*MsatsPerSat + leftover, always.
+	roundingDiffMsats int64
+
+	priceProvider pricing.PriceProvider
+	quote         string
+}
+
+// NewKoinlyExporter returns a KoinlyExporter. When addRoundingCost is true, a
+// trailing "cost" row is emitted by Finalize to account for any sats lost to
+// msat->sat truncation across all converted records. When splitFees is true,
+// the on-chain mining fee and Phoenix service fee are emitted as a
+// standalone "cost" row per transaction instead of being folded into the
+// transaction's own Fee Amount column.
+func NewKoinlyExporter(addRoundingCost, splitFees bool) *KoinlyExporter {
+	return &KoinlyExporter{addRoundingCost: addRoundingCost, splitFees: splitFees}
+}
+
+// WithPricing enables fiat net-worth enrichment: Convert will populate each
+// row's Net Worth Amount/Currency with provider's BTC price in quote at the
+// transaction's timestamp. It returns e for chaining.
+func (e *KoinlyExporter) WithPricing(provider pricing.PriceProvider, quote string) *KoinlyExporter {
+	e.priceProvider = provider
+	e.quote = quote
+	return e
+}
+
+// splitSats truncates a millisats amount into whole sats and the leftover
+// millisats the truncation lost. The split is exact: msats always equals
+// sats*converter.MsatsPerSat + leftover.
+func splitSats(msats int64) (sats, leftover int64) {
+	return msats / converter.MsatsPerSat, msats % converter.MsatsPerSat
+}
+
+// feeMsats returns the total on-chain mining fee and Phoenix service fee for
+// p, in millisats.
+func feeMsats(p *converter.PhoenixRecord) int64 {
+	return p.MiningFeeSat*converter.MsatsPerSat + p.ServiceFeeMsat
+}
+
+func (e *KoinlyExporter) Header() []string {
+	return []string{
+		"Date",
+		"Sent Amount",
+		"Sent Currency",
+		"Received Amount",
+		"Received Currency",
+		"Fee Amount",
+		"Fee Currency",
+		"Net Worth Amount",
+		"Net Worth Currency",
+		"Label",
+		"Description",
+		"TxHash",
+	}
+}
+
+// Convert maps a PhoenixRecord into a Koinly row.
+// It maps different Phoenix transaction types to appropriate Koinly fields (Sent, Received, Fee).
+func (e *KoinlyExporter) Convert(p *converter.PhoenixRecord) ([]Row, error) {
+	// Note: for lightning_sent, swap and channel-open transactions, the mining
+	// fee and Phoenix service fee are reported separately via applyFee; other
+	// types carry no separate fee.
+	k := &koinlyRecord{
+		Date:        p.Timestamp.Format(converter.KoinlyDateFormat),
+		TxHash:      p.TransactionID,
+		Description: p.Description,
+	}
+
+	// Truncate the millisats amount into whole sats, tracking the leftover
+	// exactly so it can be reconciled later instead of drifting.
+	sats, leftover := splitSats(p.AmountMillisats)
+	absSats := sats
+	if absSats < 0 {
+		absSats = -absSats
+	}
+	converter.LogVerbose("Processing Phoenix Record: %+v", p)
+	converter.LogVerbose("Calculated Sats: %d", sats)
+
+	// hasOnChainFee tracks whether this transaction type carries a mining fee
+	// and/or Phoenix service fee that should reach the exported ledger.
+	hasOnChainFee := false
+
+	// Determine the Koinly record type based on Phoenix transaction type.
+	switch p.Type {
+	case "lightning_received":
+		k.ReceivedAmount = converter.FormatBTC(sats)
+		k.ReceivedCurrency = "BTC"
+		k.Label = "lightning"
+		converter.LogVerbose("Type: lightning_received -> ReceivedAmount=%s BTC", k.ReceivedAmount)
+		e.roundingDiffMsats += leftover
+	case "lightning_sent":
+		// For sent transactions, amount_msat is negative. Use absolute value.
+		k.SentAmount = converter.FormatBTC(absSats)
+		k.SentCurrency = "BTC"
+		k.Label = "lightning"
+		converter.LogVerbose("Type: lightning_sent -> SentAmount=%s BTC", k.SentAmount)
+		e.roundingDiffMsats += leftover
+		hasOnChainFee = true
+	case "swap_in", "legacy_swap_in":
+		// Swap-in is a receipt of funds.
+		k.ReceivedAmount = converter.FormatBTC(sats)
+		k.ReceivedCurrency = "BTC"
+		k.Label = "transfer"
+		converter.LogVerbose("Type: %s -> ReceivedAmount=%s BTC", p.Type, k.ReceivedAmount)
+		e.roundingDiffMsats += leftover
+		hasOnChainFee = true
+	case "swap_out":
+		// Swap-out is a sending of funds.
+		k.SentAmount = converter.FormatBTC(absSats)
+		k.SentCurrency = "BTC"
+		k.Label = "transfer"
+		converter.LogVerbose("Type: swap_out -> SentAmount=%s BTC", k.SentAmount)
+		e.roundingDiffMsats += leftover
+		hasOnChainFee = true
+	case "channel_open", "legacy_pay_to_open":
+		// Channel open is treated as a deposit.
+		k.ReceivedAmount = converter.FormatBTC(sats)
+		k.ReceivedCurrency = "BTC"
+		k.Label = "deposit"
+		converter.LogVerbose("Type: %s -> ReceivedAmount=%s BTC", p.Type, k.ReceivedAmount)
+		e.roundingDiffMsats += leftover
+		hasOnChainFee = true
+	case "channel_close":
+		// Channel close is treated as a cost (fee) in Koinly, as it's often just a fee settlement.
+		k.FeeAmount = converter.FormatBTC(absSats)
+		k.FeeCurrency = "BTC"
+		k.Label = "cost"
+		converter.LogVerbose("Type: channel_close -> FeeAmount=%s BTC", k.FeeAmount)
+		e.roundingDiffMsats += leftover
+	default:
+		// Log unknown transaction types for awareness.
+		converter.LogVerbose("Unknown transaction type for Koinly conversion: %s. This transaction will not be fully converted.", p.Type)
+	}
+
+	var feeRow Row
+	if hasOnChainFee {
+		feeRow = e.applyFee(k, p)
+	}
+
+	if k.Label != "" {
+		e.applyNetWorth(k, absSats, p)
+	}
+
+	rows := []Row{k.toRow()}
+	if feeRow != nil {
+		rows = append(rows, feeRow)
+	}
+	return rows, nil
+}
+
+// applyFee accounts for the on-chain mining fee and Phoenix service fee on p.
+// When splitFees is set, it returns a standalone "cost" row to be appended
+// alongside k; otherwise it folds the fee directly into k's Fee Amount
+// column and returns nil.
+func (e *KoinlyExporter) applyFee(k *koinlyRecord, p *converter.PhoenixRecord) Row {
+	sats, leftover := splitSats(feeMsats(p))
+	// Always accumulate the leftover so Finalize's reconciliation stays
+	// exact, but a sub-satoshi fee (sats == 0) has nothing displayable to
+	// write, so don't fabricate a zero-amount fee row for it.
+	e.roundingDiffMsats += leftover
+	if sats == 0 {
+		return nil
+	}
+
+	amt := converter.FormatBTC(sats)
+	if e.splitFees {
+		feeRecord := &koinlyRecord{
+			Date:        k.Date,
+			FeeAmount:   amt,
+			FeeCurrency: "BTC",
+			Label:       "cost",
+			Description: fmt.Sprintf("Mining/service fee for %s", p.TransactionID),
+			TxHash:      p.TransactionID,
+		}
+		return feeRecord.toRow()
+	}
+
+	k.FeeAmount = amt
+	k.FeeCurrency = "BTC"
+	return nil
+}
+
+// applyNetWorth populates k's Net Worth Amount/Currency with the fiat value
+// of amountSats at p's timestamp, if pricing is enabled. Lookup failures
+// (offline, uncached, rate-limited) are logged and leave the fields empty
+// rather than failing the whole conversion.
+func (e *KoinlyExporter) applyNetWorth(k *koinlyRecord, amountSats int64, p *converter.PhoenixRecord) {
+	if e.priceProvider == nil || e.quote == "" {
+		return
+	}
+
+	price, err := e.priceProvider.PriceAt(p.Timestamp, e.quote)
+	if err != nil {
+		converter.LogVerbose("net worth lookup failed for %s: %v", p.TransactionID, err)
+		return
+	}
+
+	amountBTC := float64(amountSats) / converter.SatsPerBTC
+	k.NetWorthAmount = fmt.Sprintf("%.2f", amountBTC*price)
+	k.NetWorthCurrency = strings.ToUpper(e.quote)
+}
+
+// Finalize emits a trailing "cost" row accounting for sats lost to msat->sat
+// truncation across all converted records, if addRoundingCost was requested
+// and the accumulated leftover is at least half a sat (500 millisats).
+func (e *KoinlyExporter) Finalize() []Row {
+	if !e.addRoundingCost {
+		return nil
+	}
+
+	leftover := e.roundingDiffMsats
+	if leftover < 0 {
+		leftover = -leftover
+	}
+	if leftover < 500 {
+		return nil
+	}
+	roundingSats := (leftover + 500) / converter.MsatsPerSat
+	if roundingSats <= 0 {
+		return nil
+	}
+
+	costRecord := &koinlyRecord{
+		Date:        time.Now().UTC().Format(converter.KoinlyDateFormat),
+		FeeAmount:   converter.FormatBTC(roundingSats),
+		FeeCurrency: "BTC",
+		Label:       "cost",
+		Description: "Adjustment for rounding differences",
+	}
+	return []Row{costRecord.toRow()}
+}
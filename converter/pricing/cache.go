@@ -0,0 +1,131 @@
+package pricing
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheDateFormat is the UTC calendar day granularity prices are cached at.
+const cacheDateFormat = "2006-01-02"
+
+type cacheKey struct {
+	day   string
+	quote string
+}
+
+func dayKey(t time.Time, quote string) cacheKey {
+	return cacheKey{day: t.UTC().Format(cacheDateFormat), quote: strings.ToUpper(quote)}
+}
+
+// Cache is a file-backed, day-granularity store of historical BTC prices,
+// so repeated conversions of the same ledger never re-fetch the same day and
+// remain reproducible offline.
+type Cache struct {
+	path string
+
+	mu     sync.Mutex
+	prices map[cacheKey]float64
+	dirty  bool
+}
+
+// DefaultCachePath returns the default price cache location,
+// phoenix-koinly-converter/prices.csv under os.UserCacheDir (on Linux,
+// ~/.cache/phoenix-koinly-converter/prices.csv unless $XDG_CACHE_HOME is set).
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "phoenix-koinly-converter", "prices.csv"), nil
+}
+
+// LoadCache reads a Cache from path's CSV file. A missing file is not an
+// error; it simply starts out empty.
+func LoadCache(path string) (*Cache, error) {
+	c := &Cache{path: path, prices: make(map[cacheKey]float64)}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) != 3 {
+			continue
+		}
+		price, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			continue
+		}
+		c.prices[cacheKey{day: row[0], quote: strings.ToUpper(row[1])}] = price
+	}
+	return c, nil
+}
+
+// Get returns the cached BTC price in quote on t's UTC calendar day.
+func (c *Cache) Get(t time.Time, quote string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	price, ok := c.prices[dayKey(t, quote)]
+	return price, ok
+}
+
+// Set records the BTC price in quote on t's UTC calendar day.
+func (c *Cache) Set(t time.Time, quote string, price float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prices[dayKey(t, quote)] = price
+	c.dirty = true
+}
+
+// Save writes the cache back to its CSV file if anything new was fetched.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	for k, price := range c.prices {
+		row := []string{k.day, k.quote, strconv.FormatFloat(price, 'f', 8, 64)}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,96 @@
+package pricing
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheSetGetRoundTrip(t *testing.T) {
+	c, err := LoadCache(filepath.Join(t.TempDir(), "missing.csv"))
+	if err != nil {
+		t.Fatalf("unexpected error loading missing cache: %v", err)
+	}
+
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, ok := c.Get(when, "USD"); ok {
+		t.Fatalf("expected no cached price before Set")
+	}
+
+	c.Set(when, "USD", 64000.12)
+	price, ok := c.Get(when, "usd")
+	if !ok || price != 64000.12 {
+		t.Errorf("expected cached price 64000.12, got %v ok=%v", price, ok)
+	}
+}
+
+func TestCacheSaveAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	c, err := LoadCache(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c.Set(when, "USD", 64000.12)
+	if err := c.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+
+	reloaded, err := LoadCache(path)
+	if err != nil {
+		t.Fatalf("unexpected error reloading cache: %v", err)
+	}
+	price, ok := reloaded.Get(when, "USD")
+	if !ok || price != 64000.12 {
+		t.Errorf("expected reloaded price 64000.12, got %v ok=%v", price, ok)
+	}
+}
+
+type stubProvider struct {
+	price float64
+	calls int
+}
+
+func (s *stubProvider) PriceAt(t time.Time, quote string) (float64, error) {
+	s.calls++
+	return s.price, nil
+}
+
+func TestCachedProviderDedupesByDay(t *testing.T) {
+	stub := &stubProvider{price: 64000}
+	cache, err := LoadCache(filepath.Join(t.TempDir(), "prices.csv"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := NewCachedProvider(stub, cache, false)
+
+	morning := time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+
+	if _, err := p.PriceAt(morning, "USD"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.PriceAt(evening, "USD"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("expected underlying provider to be called once for the same day, got %d calls", stub.calls)
+	}
+}
+
+func TestCachedProviderNoNetworkMissesGracefully(t *testing.T) {
+	stub := &stubProvider{price: 64000}
+	cache, err := LoadCache(filepath.Join(t.TempDir(), "prices.csv"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := NewCachedProvider(stub, cache, true)
+
+	if _, err := p.PriceAt(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "USD"); err == nil {
+		t.Errorf("expected an error for an uncached price in no-network mode")
+	}
+	if stub.calls != 0 {
+		t.Errorf("expected underlying provider not to be called in no-network mode, got %d calls", stub.calls)
+	}
+}
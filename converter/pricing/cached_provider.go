@@ -0,0 +1,47 @@
+package pricing
+
+import (
+	"fmt"
+	"time"
+)
+
+// CachedProvider wraps an underlying PriceProvider with a day-granularity
+// Cache, so repeated lookups for the same calendar day are deduped and
+// conversions can be re-run offline from previously fetched prices.
+type CachedProvider struct {
+	underlying PriceProvider
+	cache      *Cache
+	noNetwork  bool
+}
+
+// NewCachedProvider returns a CachedProvider. When noNetwork is true, PriceAt
+// never calls underlying and only ever returns prices already in cache.
+func NewCachedProvider(underlying PriceProvider, cache *Cache, noNetwork bool) *CachedProvider {
+	return &CachedProvider{underlying: underlying, cache: cache, noNetwork: noNetwork}
+}
+
+// PriceAt returns the cached price for t's UTC calendar day if present,
+// otherwise fetches it from the underlying provider (unless noNetwork is
+// set) and caches the result for next time.
+func (p *CachedProvider) PriceAt(t time.Time, quote string) (float64, error) {
+	if price, ok := p.cache.Get(t, quote); ok {
+		return price, nil
+	}
+
+	if p.noNetwork {
+		return 0, fmt.Errorf("no cached price for %s %s and network lookups are disabled", t.UTC().Format(cacheDateFormat), quote)
+	}
+
+	price, err := p.underlying.PriceAt(t, quote)
+	if err != nil {
+		return 0, err
+	}
+
+	p.cache.Set(t, quote, price)
+	return price, nil
+}
+
+// Save flushes any newly fetched prices to the cache's file.
+func (p *CachedProvider) Save() error {
+	return p.cache.Save()
+}
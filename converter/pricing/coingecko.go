@@ -0,0 +1,66 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// coinGeckoHistoryURL is CoinGecko's historical-snapshot endpoint for the
+// bitcoin coin.
+const coinGeckoHistoryURL = "https://api.coingecko.com/api/v3/coins/bitcoin/history"
+
+// coinGeckoTimeout bounds how long a single history request may take, so a
+// stalled connection fails fast into the offline/cache fallback path instead
+// of hanging the whole conversion.
+const coinGeckoTimeout = 10 * time.Second
+
+// CoinGeckoProvider fetches historical BTC prices from the CoinGecko API.
+type CoinGeckoProvider struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewCoinGeckoProvider returns a CoinGeckoProvider with a bounded request
+// timeout.
+func NewCoinGeckoProvider() *CoinGeckoProvider {
+	return &CoinGeckoProvider{
+		httpClient: &http.Client{Timeout: coinGeckoTimeout},
+		baseURL:    coinGeckoHistoryURL,
+	}
+}
+
+type coinGeckoHistoryResponse struct {
+	MarketData struct {
+		CurrentPrice map[string]float64 `json:"current_price"`
+	} `json:"market_data"`
+}
+
+// PriceAt returns BTC's price in quote on t's UTC calendar day via
+// CoinGecko's /coins/bitcoin/history endpoint.
+func (p *CoinGeckoProvider) PriceAt(t time.Time, quote string) (float64, error) {
+	url := fmt.Sprintf("%s?date=%s&localization=false", p.baseURL, t.UTC().Format("02-01-2006"))
+
+	resp, err := p.httpClient.Get(url)
+	if err != nil {
+		return 0, fmt.Errorf("fetching coingecko history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("coingecko history returned status %s", resp.Status)
+	}
+
+	var data coinGeckoHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, fmt.Errorf("decoding coingecko history: %w", err)
+	}
+
+	price, ok := data.MarketData.CurrentPrice[strings.ToLower(quote)]
+	if !ok {
+		return 0, fmt.Errorf("coingecko history has no price for quote %q", quote)
+	}
+	return price, nil
+}
@@ -0,0 +1,12 @@
+// Package pricing looks up BTC's historical fiat value so conversions can be
+// enriched with a net-worth column, with a file-backed cache so repeated
+// lookups stay reproducible offline.
+package pricing
+
+import "time"
+
+// PriceProvider looks up BTC's historical price in a given quote currency.
+type PriceProvider interface {
+	// PriceAt returns BTC's price in quote (e.g. "USD", "EUR") on t's date.
+	PriceAt(t time.Time, quote string) (float64, error)
+}
@@ -2,15 +2,23 @@ package main
 
 import (
 	"flag" // Import the flag package for command-line argument parsing
+	"fmt"
 	"log"
 	"os"
 
 	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter"
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter/exporter"
+	"github.com/ExecutiveOrder6102/phoenix-koinly-converter/converter/pricing"
 )
 
 func main() {
 	// Define command line flags.
 	flag.BoolVar(&converter.Verbose, "v", false, "Enable verbose logging for debugging.")
+	format := flag.String("format", "koinly", "Output format: koinly, cointracker, or cointracking.")
+	roundingCost := flag.Bool("rounding-cost", true, "Emit a trailing adjustment row for sats lost to rounding.")
+	splitFees := flag.Bool("split-fees", false, "Emit mining/service fees as standalone cost rows instead of folding them into each transaction (koinly format only).")
+	quote := flag.String("quote", "", "Populate Net Worth columns with the BTC price in this fiat currency (e.g. USD, EUR) at each transaction's timestamp (koinly format only).")
+	noNetwork := flag.Bool("no-network", false, "Only use cached prices for --quote lookups; never hit the network.")
 	flag.Parse() // Parse command-line arguments.
 
 	// Check if a file path is provided after parsing flags.
@@ -19,22 +27,61 @@ func main() {
 	}
 	filePath := flag.Arg(0) // Get the file path from the non-flag arguments.
 
+	exp, err := exporter.New(*format, *roundingCost, *splitFees)
+	if err != nil {
+		log.Fatalf("Invalid output format: %v", err)
+	}
+
+	if *quote != "" {
+		koinlyExp, ok := exp.(*exporter.KoinlyExporter)
+		if !ok {
+			log.Fatal("--quote is only supported with --format koinly")
+		}
+
+		cachedPrices, save := setupPricing(*noNetwork)
+		koinlyExp.WithPricing(cachedPrices, *quote)
+		defer save()
+	}
+
 	f, err := os.Open(filePath)
 	if err != nil {
 		log.Fatalf("Error opening Phoenix CSV: %v", err)
 	}
 	defer f.Close()
 
-	// Create the Koinly CSV file.
-	koinlyFile, err := os.Create("koinly.csv")
+	// Create the output CSV file, named after the chosen format.
+	outPath := fmt.Sprintf("%s.csv", *format)
+	outFile, err := os.Create(outPath)
 	if err != nil {
-		log.Fatalf("Error creating Koinly CSV: %v", err)
+		log.Fatalf("Error creating output CSV: %v", err)
 	}
-	defer koinlyFile.Close()
+	defer outFile.Close()
 
-	if err := converter.Convert(f, koinlyFile); err != nil {
+	if err := exporter.Convert(f, outFile, exp); err != nil {
 		log.Fatalf("Conversion failed: %v", err)
 	}
 
-	log.Println("Conversion complete: koinly.csv created successfully.")
+	log.Printf("Conversion complete: %s created successfully.", outPath)
+}
+
+// setupPricing loads the on-disk price cache and wraps CoinGecko in it,
+// returning the resulting provider and a save func to flush newly fetched
+// prices back to disk once the conversion completes.
+func setupPricing(noNetwork bool) (*pricing.CachedProvider, func()) {
+	cachePath, err := pricing.DefaultCachePath()
+	if err != nil {
+		log.Fatalf("Error resolving price cache path: %v", err)
+	}
+
+	cache, err := pricing.LoadCache(cachePath)
+	if err != nil {
+		log.Fatalf("Error loading price cache: %v", err)
+	}
+
+	provider := pricing.NewCachedProvider(pricing.NewCoinGeckoProvider(), cache, noNetwork)
+	return provider, func() {
+		if err := provider.Save(); err != nil {
+			log.Printf("Warning: failed to save price cache: %v", err)
+		}
+	}
 }